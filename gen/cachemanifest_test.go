@@ -0,0 +1,69 @@
+// Copyright (c) 2025 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsFingerprint_SameOptionsSameFingerprint(t *testing.T) {
+	a := &Options{OutputDir: "/out", PackagePrefix: "example.com/gen", NoZap: true}
+	b := &Options{OutputDir: "/out", PackagePrefix: "example.com/gen", NoZap: true}
+
+	assert.Equal(t, optionsFingerprint(a), optionsFingerprint(b))
+}
+
+func TestOptionsFingerprint_ChangesWithOutputDir(t *testing.T) {
+	a := &Options{OutputDir: "/out/one"}
+	b := &Options{OutputDir: "/out/two"}
+
+	assert.NotEqual(t, optionsFingerprint(a), optionsFingerprint(b),
+		"a cache manifest built for one OutputDir must not look valid for another")
+}
+
+func TestOptionsFingerprint_ChangesWithGenerationAffectingFields(t *testing.T) {
+	base := Options{OutputDir: "/out", PackagePrefix: "example.com/gen"}
+	baseline := optionsFingerprint(&base)
+
+	variants := []Options{
+		{OutputDir: "/out", PackagePrefix: "example.com/other"},
+		{OutputDir: "/out", PackagePrefix: "example.com/gen", SourceRelativePaths: true},
+		{OutputDir: "/out", PackagePrefix: "example.com/gen", NoZap: true},
+		{OutputDir: "/out", PackagePrefix: "example.com/gen", OutputFile: "all.go"},
+		{OutputDir: "/out", PackagePrefix: "example.com/gen", EnumTextMarshalStrict: true},
+	}
+
+	for _, v := range variants {
+		v := v
+		assert.NotEqual(t, baseline, optionsFingerprint(&v),
+			"expected fingerprint to change for %+v", v)
+	}
+}
+
+func TestOptionsFingerprint_IgnoresCacheDirAndSink(t *testing.T) {
+	a := &Options{OutputDir: "/out", CacheDir: "/cache/one", Sink: DiskSink{Root: "/out"}}
+	b := &Options{OutputDir: "/out", CacheDir: "/cache/two", Sink: nil}
+
+	assert.Equal(t, optionsFingerprint(a), optionsFingerprint(b),
+		"CacheDir and Sink affect where output goes, not its contents, and must not bust the cache")
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2025 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.uber.org/thriftrw/gen/outputsink"
+)
+
+// DiskSink is the only sink whose Write calls a CacheDir hit may skip:
+// its files are already sitting on disk from the previous run, exactly
+// where this run would write them.
+func TestIsDurableSink_DiskSinkIsDurable(t *testing.T) {
+	assert.True(t, isDurableSink(DiskSink{Root: "/out"}))
+}
+
+// Any other sink starts empty in every process. Skipping its Write calls
+// on a CacheDir hit would silently hand the caller a partial or
+// completely empty result instead of an error, so these must never be
+// treated as durable.
+func TestIsDurableSink_OtherSinksAreNotDurable(t *testing.T) {
+	assert.False(t, isDurableSink(outputsink.NewInMemorySink()))
+
+	var buf bytesWriter
+	assert.False(t, isDurableSink(outputsink.NewZipSink(&buf)))
+	assert.False(t, isDurableSink(outputsink.NewTarSink(&buf)))
+}
+
+// bytesWriter is a minimal io.Writer for constructing archive sinks in
+// tests that only care about their type, not their written bytes.
+type bytesWriter struct{ buf []byte }
+
+func (w *bytesWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
@@ -22,21 +22,46 @@ package gen
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"go.uber.org/thriftrw/compile"
 	"go.uber.org/thriftrw/internal/plugin"
 	"go.uber.org/thriftrw/plugin/api"
+	"go.uber.org/thriftrw/version"
 
 	"go.uber.org/multierr"
 )
 
 // CodeGenerator lists possible code generators for a plugin.
 type CodeGenerator struct {
+	// ServiceGenerator is the code generation plugin to run.
+	//
+	// Deprecated: Use ServiceGenerators to run more than one plugin.
 	ServiceGenerator api.ServiceGenerator
+
+	// ServiceGenerators is an ordered chain of code generation plugins.
+	// Each plugin is given the same snapshot of the generated module and
+	// service tree, and their Files are merged together in order, so two
+	// plugins must not write to the same path. If ServiceGenerator is
+	// also set, it runs first, ahead of this chain.
+	ServiceGenerators []api.ServiceGenerator
+}
+
+// FileTransformer rewrites a generated file's contents after all plugins
+// have run and before the result is written to Options.Sink. Transformers
+// run in the order they're listed in Options.Transformers.
+type FileTransformer interface {
+	// Transform returns the contents to write for path, given the
+	// contents produced by code generation and any prior transformer.
+	Transform(path string, contents []byte) ([]byte, error)
 }
 
 // Options controls how code gets generated.
@@ -57,6 +82,19 @@ type Options struct {
 	// This must be an absolute path.
 	ThriftRoot string
 
+	// SourceRelativePaths, if true, writes the code generated for a Thrift
+	// file into the same directory as that file, rather than nesting it
+	// under a directory named after the file. For $thriftRoot/foo/bar.thrift,
+	// the default layout generates $outputDir/foo/bar/bar.go; with
+	// SourceRelativePaths, it generates $outputDir/foo/bar.go instead. This
+	// mirrors the `paths=source_relative` option protoc-gen-go users expect.
+	SourceRelativePaths bool
+
+	// SourceRoot overrides ThriftRoot as the root against which
+	// SourceRelativePaths computes output paths. If empty, ThriftRoot is
+	// used.
+	SourceRoot string
+
 	// NoRecurse determines whether code should be generated for included Thrift
 	// files as well. If true, code gets generated only for the first module.
 	NoRecurse bool
@@ -88,10 +126,246 @@ type Options struct {
 	// Generates an error on MarshalText and MarshalJSON if the enum value is
 	// unrecognized.
 	EnumTextMarshalStrict bool
+
+	// Sink receives the generated files. If nil, a DiskSink rooted at
+	// OutputDir is used, preserving the historical behavior of writing
+	// directly to the local filesystem.
+	Sink OutputSink
+
+	// Transformers run, in order, over every generated file's contents
+	// after all plugins have run and before the result is handed to Sink.
+	Transformers []FileTransformer
+
+	// CacheDir, if set, enables incremental generation. A manifest
+	// recording the hashes of the Thrift inputs and generated outputs
+	// from the previous run is kept at CacheDir/manifest.json: if none of
+	// the Thrift inputs changed, generation is skipped entirely; failing
+	// that, individual output files whose contents are unchanged are not
+	// rewritten. Both skips apply only when Sink is a DiskSink (or nil) and
+	// the manifest was written by the same ThriftRW version under the same
+	// generation options; otherwise every file is generated and written.
+	CacheDir string
+
+	// Concurrency controls how many modules are generated in parallel.
+	// 0 (the default) uses GOMAXPROCS. 1 disables parallelism, generating
+	// modules one at a time in a deterministic order, which is useful for
+	// debugging. The generated output is the same for every setting.
+	Concurrency int
+}
+
+// manifestFormatVersion is bumped whenever the manifest schema below
+// changes in a way that requires existing manifests to be discarded.
+const manifestFormatVersion = 1
+
+// manifest is the schema of CacheDir/manifest.json.
+type manifest struct {
+	// Version is the manifestFormatVersion this manifest was written
+	// with. A mismatch forces a full regeneration.
+	Version int `json:"version"`
+
+	// ThriftRWVersion is the ThriftRW version that produced this
+	// manifest. A mismatch forces a full regeneration, since a new
+	// ThriftRW release may change output for unchanged inputs.
+	ThriftRWVersion string `json:"thriftrwVersion"`
+
+	// OptionsFingerprint summarizes the Options fields that affect the
+	// bytes Generate produces (OutputDir, PackagePrefix, the plugin
+	// chain, etc). A mismatch forces a full regeneration, since the same
+	// Thrift inputs can legitimately generate different output under
+	// different options.
+	OptionsFingerprint string `json:"optionsFingerprint"`
+
+	// Inputs maps each Thrift file consumed (relative to ThriftRoot) to
+	// the sha256 of its contents.
+	Inputs map[string]string `json:"inputs"`
+
+	// Outputs maps each generated file (relative to OutputDir) to the
+	// sha256 of its contents.
+	Outputs map[string]string `json:"outputs"`
+}
+
+// optionsFingerprint summarizes the Options fields that affect the bytes
+// Generate produces. It deliberately excludes Sink and CacheDir, which
+// affect where output goes but not what it contains.
+func optionsFingerprint(o *Options) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "OutputDir=%s\n", o.OutputDir)
+	fmt.Fprintf(&sb, "PackagePrefix=%s\n", o.PackagePrefix)
+	fmt.Fprintf(&sb, "ThriftRoot=%s\n", o.ThriftRoot)
+	fmt.Fprintf(&sb, "SourceRelativePaths=%v\n", o.SourceRelativePaths)
+	fmt.Fprintf(&sb, "SourceRoot=%s\n", o.SourceRoot)
+	fmt.Fprintf(&sb, "NoRecurse=%v\n", o.NoRecurse)
+	fmt.Fprintf(&sb, "NoVersionCheck=%v\n", o.NoVersionCheck)
+	fmt.Fprintf(&sb, "NoTypes=%v\n", o.NoTypes)
+	fmt.Fprintf(&sb, "NoConstants=%v\n", o.NoConstants)
+	fmt.Fprintf(&sb, "NoServiceHelpers=%v\n", o.NoServiceHelpers)
+	fmt.Fprintf(&sb, "NoEmbedIDL=%v\n", o.NoEmbedIDL)
+	fmt.Fprintf(&sb, "NoZap=%v\n", o.NoZap)
+	fmt.Fprintf(&sb, "OutputFile=%s\n", o.OutputFile)
+	fmt.Fprintf(&sb, "EnumTextMarshalStrict=%v\n", o.EnumTextMarshalStrict)
+	fmt.Fprintf(&sb, "Plugin.ServiceGenerator=%T\n", o.Plugin.ServiceGenerator)
+	for _, p := range o.Plugin.ServiceGenerators {
+		fmt.Fprintf(&sb, "Plugin.ServiceGenerators+=%T\n", p)
+	}
+	for _, tr := range o.Transformers {
+		fmt.Fprintf(&sb, "Transformers+=%T\n", tr)
+	}
+	return sha256Hex([]byte(sb.String()))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func sameHashes(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// hashThriftInputs hashes the contents of every Thrift file reachable
+// from modules, keyed by their path relative to ThriftRoot.
+func hashThriftInputs(modules []*compile.Module, i thriftPackageImporter) (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	visit := func(m *compile.Module) error {
+		relPath, err := i.RelativeThriftFilePath(m.ThriftPath)
+		if err != nil {
+			return err
+		}
+		if _, ok := hashes[relPath]; ok {
+			return nil
+		}
+
+		contents, err := os.ReadFile(m.ThriftPath)
+		if err != nil {
+			return err
+		}
+		hashes[relPath] = sha256Hex(contents)
+		return nil
+	}
+
+	for _, m := range modules {
+		if err := m.Walk(visit); err != nil {
+			return nil, err
+		}
+	}
+
+	return hashes, nil
+}
+
+// loadManifest reads the manifest stored at cacheDir/manifest.json.
+func loadManifest(cacheDir string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(cacheDir, "manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// writeManifest atomically writes m to cacheDir/manifest.json so that an
+// interrupted run never leaves a corrupt or stale manifest behind.
+func writeManifest(cacheDir string, m *manifest) error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return fmt.Errorf("could not create cache directory %q: %v", cacheDir, err)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal cache manifest: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(cacheDir, "manifest-*.json.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary manifest file: %v", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("could not write temporary manifest file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("could not close temporary manifest file: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(cacheDir, "manifest.json")); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("could not rename temporary manifest file: %v", err)
+	}
+
+	return nil
+}
+
+// OutputSink receives the files generated by Generate. Implementations
+// allow callers to redirect generated output away from the local
+// filesystem, e.g. into memory or an archive.
+type OutputSink interface {
+	// Write writes contents to relPath, which is relative to OutputDir.
+	// Implementations must create any intermediate directories needed to
+	// hold the file.
+	Write(relPath string, contents []byte) error
+}
+
+// DiskSink is the default OutputSink. It writes files to the local
+// filesystem rooted at Root.
+type DiskSink struct {
+	// Root is the directory under which all files are written.
+	Root string
+}
+
+// Write implements OutputSink.
+func (s DiskSink) Write(relPath string, contents []byte) error {
+	fullPath := filepath.Join(s.Root, relPath)
+	directory := filepath.Dir(fullPath)
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return fmt.Errorf("could not create directory %q: %v", directory, err)
+	}
+
+	if err := os.WriteFile(fullPath, contents, 0644); err != nil {
+		return fmt.Errorf("failed to write %q: %v", fullPath, err)
+	}
+
+	return nil
+}
+
+// isDurableSink reports whether sink already holds the output of a
+// previous run the way DiskSink does, so that a CacheDir hit - whole-run
+// or per-file - can safely skip writing to it. Any other sink (an
+// InMemorySink, ZipSink, or TarSink) starts empty in every process;
+// skipping its Write calls on a cache hit would silently hand the caller
+// a partial or completely empty result instead of an error.
+func isDurableSink(sink OutputSink) bool {
+	_, ok := sink.(DiskSink)
+	return ok
 }
 
 // Generate generates code based on the given options.
 func Generate(m *compile.Module, o *Options) error {
+	return GenerateAll([]*compile.Module{m}, o)
+}
+
+// GenerateAll generates code for one or more root Thrift files sharing a
+// single ThriftRoot and OutputDir. A module that is reachable via
+// `include` from one root and also passed as a root itself (or reachable
+// from more than one root) has its code generated exactly once.
+func GenerateAll(modules []*compile.Module, o *Options) error {
 	if !filepath.IsAbs(o.ThriftRoot) {
 		return fmt.Errorf(
 			"ThriftRoot must be an absolute path: %q is not absolute",
@@ -104,75 +378,175 @@ func Generate(m *compile.Module, o *Options) error {
 			o.OutputDir)
 	}
 
+	if o.SourceRoot != "" && !filepath.IsAbs(o.SourceRoot) {
+		return fmt.Errorf(
+			"SourceRoot must be an absolute path: %q is not absolute",
+			o.SourceRoot)
+	}
+
 	importer := thriftPackageImporter{
-		ImportPrefix: o.PackagePrefix,
-		ThriftRoot:   o.ThriftRoot,
+		ImportPrefix:        o.PackagePrefix,
+		ThriftRoot:          o.ThriftRoot,
+		SourceRelativePaths: o.SourceRelativePaths,
+		SourceRoot:          o.SourceRoot,
+	}
+
+	sink := o.Sink
+	if sink == nil {
+		sink = DiskSink{Root: o.OutputDir}
+	}
+
+	// The manifest's hit checks below skip writing to the sink (the
+	// whole-run check skips generation as well), so they're only safe for
+	// a sink that is durable across process runs (see isDurableSink).
+	sinkIsDisk := isDurableSink(sink)
+
+	var prevManifest *manifest
+	var inputHashes map[string]string
+	if o.CacheDir != "" {
+		var err error
+		if inputHashes, err = hashThriftInputs(modules, importer); err != nil {
+			return err
+		}
+
+		if m, err := loadManifest(o.CacheDir); err == nil {
+			prevManifest = m
+			if sinkIsDisk &&
+				m.Version == manifestFormatVersion &&
+				m.ThriftRWVersion == version.Version &&
+				m.OptionsFingerprint == optionsFingerprint(o) &&
+				sameHashes(m.Inputs, inputHashes) {
+				// Nothing upstream has changed since the last run, and
+				// sink is the disk that already holds the previous
+				// run's output.
+				return nil
+			}
+		}
 	}
 
 	// Mapping of filenames relative to OutputDir to their contents.
 	files := make(map[string][]byte)
 	genBuilder := newGenerateServiceBuilder(importer)
 
-	generate := func(m *compile.Module) error {
-		path, contents, err := generateModule(m, importer, genBuilder, o)
-		if err != nil {
-			return generateError{Name: m.ThriftPath, Reason: err}
+	// Root Modules correspond to the Thrift files that ThriftRW is
+	// called with.
+	for _, m := range modules {
+		if _, err := genBuilder.AddRootModule(m.ThriftPath); err != nil {
+			return err
 		}
+	}
 
-		if err := addFile(files, path, contents); err != nil {
-			return generateError{Name: m.ThriftPath, Reason: err}
+	// Collect, in a deterministic order, the set of modules we need to
+	// generate code for. If the user used --no-recurse, we're not going to
+	// generate code for included modules. Specifying an OutputFile also
+	// means that code for included modules should not be generated, since
+	// code for multiple modules cannot be compiled into a single file.
+	// generated dedups a module reachable from more than one root (as an
+	// include of one and the root of another, or as an include of both)
+	// so its code is only generated once.
+	var toGenerate []*compile.Module
+	generated := make(map[string]bool)
+	collect := func(m *compile.Module) error {
+		if generated[m.ThriftPath] {
+			return nil
 		}
-
+		generated[m.ThriftPath] = true
+		toGenerate = append(toGenerate, m)
 		return nil
 	}
 
-	// Root Modules correspond to the Thrift files that ThriftRW is
-	// called with. Currently, ThriftRW can only be called with one
-	// Thrift file at a time.
-	if _, err := genBuilder.AddRootModule(m.ThriftPath); err != nil {
+	for _, m := range modules {
+		if o.NoRecurse || len(o.OutputFile) > 0 {
+			if err := collect(m); err != nil {
+				return err
+			}
+		} else {
+			if err := m.Walk(collect); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := generateAll(toGenerate, importer, genBuilder, o, files); err != nil {
 		return err
 	}
 
-	// Note that we call generate directly on only those modules that we need
-	// to generate code for. If the user used --no-recurse, we're not going to
-	// generate code for included modules.
-	// Specifying an OutputFile file also means that code for included modules
-	// should not be generated, since code for multiple modules cannot
-	// be compiled into a single file.
-	if o.NoRecurse || len(o.OutputFile) > 0 {
-		if err := generate(m); err != nil {
+	plugins := o.Plugin.ServiceGenerators
+	if o.Plugin.ServiceGenerator != nil {
+		plugins = append([]api.ServiceGenerator{o.Plugin.ServiceGenerator}, plugins...)
+	}
+	if len(plugins) == 0 {
+		plugins = []api.ServiceGenerator{plugin.EmptyServiceGenerator}
+	}
+
+	built := genBuilder.Build()
+	for _, plug := range plugins {
+		res, err := plug.Generate(built)
+		if err != nil {
 			return err
 		}
-	} else {
-		if err := m.Walk(generate); err != nil {
+
+		if err := mergeFiles(files, res.Files); err != nil {
 			return err
 		}
 	}
 
-	plug := o.Plugin.ServiceGenerator
-	if plug == nil {
-		plug = plugin.EmptyServiceGenerator
-	}
-
-	res, err := plug.Generate(genBuilder.Build())
-	if err != nil {
-		return err
+	for _, t := range o.Transformers {
+		for relPath, contents := range files {
+			out, err := t.Transform(relPath, contents)
+			if err != nil {
+				return fmt.Errorf("could not transform %q: %v", relPath, err)
+			}
+			files[relPath] = out
+		}
 	}
 
-	if err := mergeFiles(files, res.Files); err != nil {
-		return err
+	var newManifest *manifest
+	var fingerprint string
+	if o.CacheDir != "" {
+		fingerprint = optionsFingerprint(o)
+		newManifest = &manifest{
+			Version:            manifestFormatVersion,
+			ThriftRWVersion:    version.Version,
+			OptionsFingerprint: fingerprint,
+			Inputs:             inputHashes,
+			Outputs:            make(map[string]string, len(files)),
+		}
 	}
 
-	for relPath, contents := range files {
-		fullPath := filepath.Join(o.OutputDir, relPath)
-		directory := filepath.Dir(fullPath)
+	// A previous manifest's per-file output hashes are only meaningful to
+	// compare against if it was produced under the same options; a stale
+	// manifest from a run with, say, a different OutputDir or plugin
+	// chain must not suppress writes just because a hash happens to
+	// match. They're also only safe to act on for a durable, disk-backed
+	// sink (see sinkIsDisk above) - skipping a Write call into a
+	// freshly-constructed InMemorySink/ZipSink/TarSink would silently
+	// drop that file from this process's result.
+	prevOutputsUsable := sinkIsDisk && prevManifest != nil && prevManifest.OptionsFingerprint == fingerprint
+
+	// files is a map, so its iteration order is randomized per run. Sinks
+	// that bake write order into their output bytes (ZipSink, TarSink)
+	// would otherwise produce a different archive every time for the same
+	// generated files, defeating hermetic, diffable output. Writing in a
+	// fixed, sorted order makes every sink's output deterministic.
+	for _, relPath := range sortStringKeys(files) {
+		contents := files[relPath]
+		if newManifest != nil {
+			hash := sha256Hex(contents)
+			newManifest.Outputs[relPath] = hash
+			if prevOutputsUsable && prevManifest.Outputs[relPath] == hash {
+				continue
+			}
+		}
 
-		if err := os.MkdirAll(directory, 0755); err != nil {
-			return fmt.Errorf("could not create directory %q: %v", directory, err)
+		if err := sink.Write(relPath, contents); err != nil {
+			return err
 		}
+	}
 
-		if err := os.WriteFile(fullPath, contents, 0644); err != nil {
-			return fmt.Errorf("failed to write %q: %v", fullPath, err)
+	if newManifest != nil {
+		if err := writeManifest(o.CacheDir, newManifest); err != nil {
+			return err
 		}
 	}
 
@@ -184,6 +558,29 @@ func normalizePackageName(p string) string {
 	return strings.Replace(filepath.Base(p), "-", "_", -1)
 }
 
+// packageNameForFile derives the Go package name to emit for a Thrift file
+// whose package directory, relative to OutputDir, is packageRelPath.
+//
+// packageRelPath, not the Thrift file's own base name, is normally what
+// determines the package name: under SourceRelativePaths, sibling Thrift
+// files in the same directory share one output directory and therefore
+// must share one Go package name. In the default layout, packageRelPath
+// already ends in the file's base name, so this is equivalent to using
+// thriftBaseName directly.
+//
+// packageRelPath is "." for a Thrift file that sits directly under
+// ThriftRoot/SourceRoot with no subdirectory (filepath.Rel of a directory
+// against itself); filepath.Base(".") is itself ".", which isn't a valid
+// Go package name, so fall back to the Thrift file's own base name in
+// that case.
+func packageNameForFile(packageRelPath, thriftBaseName string) string {
+	base := filepath.Base(packageRelPath)
+	if base == "." {
+		base = thriftBaseName
+	}
+	return normalizePackageName(base)
+}
+
 // ThriftPackageImporter determines import paths from a Thrift root.
 type ThriftPackageImporter interface {
 	// RelativePackage returns the import path for the top-level package of the
@@ -202,10 +599,23 @@ type ThriftPackageImporter interface {
 type thriftPackageImporter struct {
 	ImportPrefix string
 	ThriftRoot   string
+
+	// SourceRelativePaths and SourceRoot mirror the Options fields of the
+	// same name; see their documentation for details.
+	SourceRelativePaths bool
+	SourceRoot          string
 }
 
 func (i thriftPackageImporter) RelativePackage(file string) (string, error) {
-	return filepath.Rel(i.ThriftRoot, strings.TrimSuffix(file, ".thrift"))
+	rel := strings.TrimSuffix(file, ".thrift")
+	if i.SourceRelativePaths {
+		root := i.SourceRoot
+		if root == "" {
+			root = i.ThriftRoot
+		}
+		return filepath.Rel(root, filepath.Dir(rel))
+	}
+	return filepath.Rel(i.ThriftRoot, rel)
 }
 
 func (i thriftPackageImporter) RelativeThriftFilePath(file string) (string, error) {
@@ -237,12 +647,139 @@ func addFile(dest map[string][]byte, path string, contents []byte) error {
 	return nil
 }
 
+// registerModule records m, and every module it includes, with builder,
+// along with m's own services as root services. builder is not safe for
+// concurrent use, so this must be called serially, in a fixed order, for
+// every module in toGenerate before any parallel codegen work starts;
+// doing so under a shared mutex instead would leave the order - and
+// therefore the contents of builder.Build() handed to every plugin -
+// dependent on goroutine scheduling.
+func registerModule(m *compile.Module, builder *generateServiceBuilder) error {
+	addModules := func(m *compile.Module) error {
+		_, err := builder.AddModule(m.ThriftPath)
+		return err
+	}
+	if err := m.Walk(addModules); err != nil {
+		return err
+	}
+
+	// generateModule gets called only for those modules for which we
+	// need to generate code. With --no-recurse, generateModule is
+	// called only on the root file specified by the user and not its
+	// included modules. Only services defined in these files are
+	// considered root services; plugins will generate code only for
+	// root services, even though they have information about the whole
+	// service tree.
+	for _, serviceName := range sortStringKeys(m.Services) {
+		if _, err := builder.AddRootService(m.Services[serviceName]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generateAll registers every module in toGenerate with builder, then runs
+// generateModule for each, writing the results into files, and returns
+// the first error encountered.
+//
+// Registration with builder happens in a single serial pass, in
+// toGenerate order, so that builder.Build() is deterministic regardless
+// of concurrency. The (CPU-bound) codegen itself then runs using up to
+// o.Concurrency workers (GOMAXPROCS by default). Regardless of how many
+// workers run or the order in which they finish, results are merged into
+// files in the order toGenerate was collected, so the output - and the
+// first error reported, if any - is deterministic.
+func generateAll(
+	toGenerate []*compile.Module,
+	i thriftPackageImporter,
+	builder *generateServiceBuilder,
+	o *Options,
+	files map[string][]byte,
+) error {
+	for _, m := range toGenerate {
+		if err := registerModule(m, builder); err != nil {
+			return generateError{Name: m.ThriftPath, Reason: err}
+		}
+	}
+
+	concurrency := o.Concurrency
+	if concurrency == 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	type result struct {
+		path     string
+		contents []byte
+		err      error
+	}
+	results := make([]result, len(toGenerate))
+
+	runIndexed(len(toGenerate), concurrency, func(idx int) {
+		path, contents, err := generateModule(toGenerate[idx], i, o)
+		results[idx] = result{path: path, contents: contents, err: err}
+	})
+
+	for idx, r := range results {
+		if r.err != nil {
+			return generateError{Name: toGenerate[idx].ThriftPath, Reason: r.err}
+		}
+		if err := addFile(files, r.path, r.contents); err != nil {
+			return generateError{Name: toGenerate[idx].ThriftPath, Reason: err}
+		}
+	}
+
+	return nil
+}
+
+// runIndexed calls work(idx) once for every idx in [0, n), using up to
+// concurrency workers, and blocks until all calls have returned. If
+// concurrency is less than 2, indices are run on the caller's goroutine,
+// in ascending order, making generation fully deterministic for debugging
+// (Options.Concurrency == 1).
+//
+// work is responsible for its own synchronization if it touches shared
+// state; callers typically have each call write only to results[idx] of a
+// pre-sized slice, which requires no locking and keeps the final result
+// ordered by idx regardless of which worker finished it, or when.
+func runIndexed(n, concurrency int, work func(idx int)) {
+	if concurrency > n {
+		concurrency = n
+	}
+
+	if concurrency < 2 {
+		for idx := 0; idx < n; idx++ {
+			work(idx)
+		}
+		return
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				work(idx)
+			}
+		}()
+	}
+	for idx := 0; idx < n; idx++ {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+}
+
 // generateModule generates the code for the given Thrift file and returns the
 // path to the output file relative to OutputDir and the contents of the file.
+// It does not touch a generateServiceBuilder, so it is safe to call
+// concurrently for different modules; see registerModule for the
+// builder-registration step this must be paired with.
 func generateModule(
 	m *compile.Module,
 	i thriftPackageImporter,
-	builder *generateServiceBuilder,
 	o *Options,
 ) (outputFilepath string, contents []byte, err error) {
 	// packageRelPath is the path relative to outputDir into which we'll be
@@ -255,10 +792,13 @@ func generateModule(
 		return "", nil, err
 	}
 	// TODO(abg): Prefer top-level package name from `namespace go` directive.
-	outputFilename := filepath.Base(packageRelPath)
+	thriftBaseName := strings.TrimSuffix(filepath.Base(m.ThriftPath), ".thrift")
 
 	// Output file name defaults to the package name.
-	outputFilename = outputFilename + ".go"
+	// More precisely, it is the Thrift file's base name: that matches the
+	// package name in the default layout, but not under SourceRelativePaths,
+	// where the package is named after the directory.
+	outputFilename := thriftBaseName + ".go"
 	if len(o.OutputFile) > 0 {
 		outputFilename = o.OutputFile
 	}
@@ -271,8 +811,7 @@ func generateModule(
 		return "", nil, err
 	}
 
-	// converts package name from ab-def to ab_def for golang code generation
-	normalizedPackageName := normalizePackageName(filepath.Base(packageRelPath))
+	normalizedPackageName := packageNameForFile(packageRelPath, thriftBaseName)
 	g := NewGenerator(&GeneratorOptions{
 		Importer:              i,
 		ImportPath:            importPath,
@@ -303,33 +842,9 @@ func generateModule(
 		}
 	}
 
-	addModules := func(m *compile.Module) error {
-		_, err := builder.AddModule(m.ThriftPath)
-		return err
-	}
-
-	if err := m.Walk(addModules); err != nil {
-		return "", nil, err
-	}
-
 	// Services must be generated last because names of user-defined types take
 	// precedence over the names we pick for the service types.
 	if len(m.Services) > 0 {
-		for _, serviceName := range sortStringKeys(m.Services) {
-			service := m.Services[serviceName]
-
-			// generateModule gets called only for those modules for which we
-			// need to generate code. With --no-recurse, generateModule is
-			// called only on the root file specified by the user and not its
-			// included modules. Only services defined in these files are
-			// considered root services; plugins will generate code only for
-			// root services, even though they have information about the
-			// whole service tree.
-			if _, err := builder.AddRootService(service); err != nil {
-				return "", nil, err
-			}
-		}
-
 		if err = Services(g, m.Services); err != nil {
 			return "", nil, fmt.Errorf("could not generate code for services %v", err)
 		}
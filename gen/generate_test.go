@@ -0,0 +1,83 @@
+// Copyright (c) 2025 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunIndexed_VisitsEveryIndexExactlyOnce(t *testing.T) {
+	const n = 50
+
+	for _, concurrency := range []int{1, 2, 4, 0, n * 2} {
+		var mu sync.Mutex
+		seen := make(map[int]int, n)
+
+		runIndexed(n, concurrency, func(idx int) {
+			mu.Lock()
+			seen[idx]++
+			mu.Unlock()
+		})
+
+		assert.Len(t, seen, n, "concurrency=%d: expected every index to be visited", concurrency)
+		for idx := 0; idx < n; idx++ {
+			assert.Equal(t, 1, seen[idx], "concurrency=%d: index %d visited more than once", concurrency, idx)
+		}
+	}
+}
+
+func TestRunIndexed_ResultOrderIsDeterministicAcrossConcurrency(t *testing.T) {
+	const n = 64
+
+	// results[idx] is only ever written by the call for that idx, so the
+	// final slice must be identical regardless of how many workers ran or
+	// in what order they happened to finish.
+	want := make([]int, n)
+	for idx := range want {
+		want[idx] = idx * idx
+	}
+
+	for _, concurrency := range []int{1, 3, 8, 0} {
+		got := make([]int, n)
+		runIndexed(n, concurrency, func(idx int) {
+			got[idx] = idx * idx
+		})
+		assert.Equal(t, want, got, "concurrency=%d produced a different result order", concurrency)
+	}
+}
+
+func TestRunIndexed_ConcurrencyOneRunsSequentiallyInOrder(t *testing.T) {
+	const n = 20
+
+	var order []int
+	runIndexed(n, 1, func(idx int) {
+		order = append(order, idx)
+	})
+
+	want := make([]int, n)
+	for idx := range want {
+		want[idx] = idx
+	}
+	assert.Equal(t, want, order)
+}
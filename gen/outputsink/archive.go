@@ -0,0 +1,96 @@
+// Copyright (c) 2025 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package outputsink
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// ZipSink streams generated files into a zip archive instead of writing
+// them to disk.
+type ZipSink struct {
+	w *zip.Writer
+}
+
+// NewZipSink wraps w to receive the generated files as a zip archive.
+// Callers must call Close once generation is done to flush the archive's
+// central directory.
+func NewZipSink(w io.Writer) *ZipSink {
+	return &ZipSink{w: zip.NewWriter(w)}
+}
+
+// Write adds relPath to the archive with the given contents.
+func (s *ZipSink) Write(relPath string, contents []byte) error {
+	f, err := s.w.Create(filepath.ToSlash(relPath))
+	if err != nil {
+		return fmt.Errorf("could not add %q to zip archive: %v", relPath, err)
+	}
+
+	_, err = f.Write(contents)
+	return err
+}
+
+// Close finalizes the zip archive. It must be called after generation is
+// done.
+func (s *ZipSink) Close() error {
+	return s.w.Close()
+}
+
+// TarSink streams generated files into a tar archive instead of writing
+// them to disk.
+type TarSink struct {
+	w *tar.Writer
+}
+
+// NewTarSink wraps w to receive the generated files as a tar archive.
+// Callers must call Close once generation is done to flush the archive's
+// trailer.
+func NewTarSink(w io.Writer) *TarSink {
+	return &TarSink{w: tar.NewWriter(w)}
+}
+
+// Write adds relPath to the archive with the given contents.
+func (s *TarSink) Write(relPath string, contents []byte) error {
+	hdr := &tar.Header{
+		Name:    filepath.ToSlash(relPath),
+		Mode:    0644,
+		Size:    int64(len(contents)),
+		ModTime: time.Unix(0, 0),
+	}
+
+	if err := s.w.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("could not add %q to tar archive: %v", relPath, err)
+	}
+
+	_, err := s.w.Write(contents)
+	return err
+}
+
+// Close finalizes the tar archive. It must be called after generation is
+// done.
+func (s *TarSink) Close() error {
+	return s.w.Close()
+}
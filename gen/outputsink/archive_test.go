@@ -0,0 +1,87 @@
+// Copyright (c) 2025 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package outputsink
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZipSink_RoundTrips(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewZipSink(&buf)
+
+	require.NoError(t, sink.Write("foo/bar.go", []byte("package bar")))
+	require.NoError(t, sink.Write("foo/baz.go", []byte("package baz")))
+	require.NoError(t, sink.Close())
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	got := make(map[string]string, len(r.File))
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		contents, err := io.ReadAll(rc)
+		require.NoError(t, rc.Close())
+		require.NoError(t, err)
+		got[f.Name] = string(contents)
+	}
+
+	assert.Equal(t, map[string]string{
+		"foo/bar.go": "package bar",
+		"foo/baz.go": "package baz",
+	}, got)
+}
+
+func TestTarSink_RoundTrips(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewTarSink(&buf)
+
+	require.NoError(t, sink.Write("foo/bar.go", []byte("package bar")))
+	require.NoError(t, sink.Write("foo/baz.go", []byte("package baz")))
+	require.NoError(t, sink.Close())
+
+	r := tar.NewReader(&buf)
+	got := make(map[string]string)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		contents, err := io.ReadAll(r)
+		require.NoError(t, err)
+		got[hdr.Name] = string(contents)
+	}
+
+	assert.Equal(t, map[string]string{
+		"foo/bar.go": "package bar",
+		"foo/baz.go": "package baz",
+	}, got)
+}
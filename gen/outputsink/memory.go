@@ -0,0 +1,49 @@
+// Copyright (c) 2025 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package outputsink provides gen.OutputSink implementations that do not
+// write generated code to the local filesystem.
+package outputsink
+
+import "path/filepath"
+
+// InMemorySink collects generated files in memory instead of writing them
+// to disk. It is useful for callers that embed ThriftRW in tests or
+// tooling that wants to inspect generated output without touching the
+// filesystem.
+type InMemorySink struct {
+	// Files holds the generated output, keyed by path relative to the
+	// configured OutputDir.
+	Files map[string][]byte
+}
+
+// NewInMemorySink builds an empty InMemorySink.
+func NewInMemorySink() *InMemorySink {
+	return &InMemorySink{Files: make(map[string][]byte)}
+}
+
+// Write records contents under relPath.
+func (s *InMemorySink) Write(relPath string, contents []byte) error {
+	if s.Files == nil {
+		s.Files = make(map[string][]byte)
+	}
+	s.Files[filepath.ToSlash(relPath)] = contents
+	return nil
+}
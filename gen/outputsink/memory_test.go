@@ -0,0 +1,47 @@
+// Copyright (c) 2025 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package outputsink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemorySink_CollectsWrites(t *testing.T) {
+	sink := NewInMemorySink()
+
+	require.NoError(t, sink.Write("foo/bar.go", []byte("package bar")))
+	require.NoError(t, sink.Write("foo/baz.go", []byte("package baz")))
+
+	assert.Equal(t, map[string][]byte{
+		"foo/bar.go": []byte("package bar"),
+		"foo/baz.go": []byte("package baz"),
+	}, sink.Files)
+}
+
+func TestInMemorySink_ZeroValueIsUsable(t *testing.T) {
+	var sink InMemorySink
+
+	require.NoError(t, sink.Write("a.go", []byte("package a")))
+	assert.Equal(t, []byte("package a"), sink.Files["a.go"])
+}
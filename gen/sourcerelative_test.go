@@ -0,0 +1,100 @@
+// Copyright (c) 2025 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Two sibling Thrift files under SourceRelativePaths land in the same
+// output directory, so they must resolve to the same Go package name and
+// the same import path - anything else produces a directory that isn't a
+// valid Go package.
+func TestSourceRelativePaths_SiblingFilesShareOnePackage(t *testing.T) {
+	importer := thriftPackageImporter{
+		ImportPrefix:        "example.com/gen",
+		ThriftRoot:          "/thrift",
+		SourceRelativePaths: true,
+	}
+
+	aPath := "/thrift/foo/a.thrift"
+	bPath := "/thrift/foo/b.thrift"
+
+	aRel, err := importer.RelativePackage(aPath)
+	require.NoError(t, err)
+	bRel, err := importer.RelativePackage(bPath)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", aRel)
+	assert.Equal(t, aRel, bRel, "sibling files must share an output directory")
+
+	// This is the same derivation generateModule uses for the emitted
+	// `package` declaration: it must agree across siblings.
+	assert.Equal(t,
+		packageNameForFile(aRel, "a"),
+		packageNameForFile(bRel, "b"),
+	)
+
+	aImport, err := importer.Package(aPath)
+	require.NoError(t, err)
+	bImport, err := importer.Package(bPath)
+	require.NoError(t, err)
+	assert.Equal(t, aImport, bImport, "sibling files must resolve to the same import path")
+	assert.Equal(t, "example.com/gen/foo", aImport)
+}
+
+// Distinct Thrift files in different directories must still resolve to
+// distinct packages under SourceRelativePaths.
+func TestSourceRelativePaths_DistinctDirectoriesStayDistinct(t *testing.T) {
+	importer := thriftPackageImporter{
+		ImportPrefix:        "example.com/gen",
+		ThriftRoot:          "/thrift",
+		SourceRelativePaths: true,
+	}
+
+	fooImport, err := importer.Package("/thrift/foo/a.thrift")
+	require.NoError(t, err)
+	barImport, err := importer.Package("/thrift/bar/a.thrift")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, fooImport, barImport)
+}
+
+// A Thrift file directly under ThriftRoot, with no subdirectory, must
+// still resolve to a valid Go package name. RelativePackage returns "."
+// in this case (filepath.Rel of a directory against itself), and
+// filepath.Base(".") is itself ".", which packageNameForFile must not
+// use verbatim.
+func TestSourceRelativePaths_RootLevelFileGetsValidPackageName(t *testing.T) {
+	importer := thriftPackageImporter{
+		ImportPrefix:        "example.com/gen",
+		ThriftRoot:          "/thrift",
+		SourceRelativePaths: true,
+	}
+
+	rel, err := importer.RelativePackage("/thrift/foo.thrift")
+	require.NoError(t, err)
+	assert.Equal(t, ".", rel, "a root-level file's RelativePackage is the directory-against-itself case")
+
+	assert.Equal(t, "foo", packageNameForFile(rel, "foo"))
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2025 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Sinks that bake write order into their output bytes (ZipSink, TarSink)
+// need files written in a fixed order to produce byte-identical archives
+// across runs, since map iteration order is randomized per run.
+func TestSortStringKeys_FilesWrittenInSortedOrder(t *testing.T) {
+	files := map[string][]byte{
+		"foo/z.go": []byte("z"),
+		"foo/a.go": []byte("a"),
+		"bar/m.go": []byte("m"),
+	}
+
+	var writeOrder []string
+	for _, relPath := range sortStringKeys(files) {
+		writeOrder = append(writeOrder, relPath)
+	}
+
+	assert.Equal(t, []string{"bar/m.go", "foo/a.go", "foo/z.go"}, writeOrder)
+}
+
+// recordingSink records the order in which relPath is written to it,
+// standing in for ZipSink/TarSink's order-sensitive byte layout.
+type recordingSink struct {
+	written []string
+}
+
+func (s *recordingSink) Write(relPath string, contents []byte) error {
+	s.written = append(s.written, relPath)
+	return nil
+}
+
+func TestSortStringKeys_DeterministicAcrossRepeatedRuns(t *testing.T) {
+	files := map[string][]byte{
+		"c.go": []byte("c"),
+		"a.go": []byte("a"),
+		"b.go": []byte("b"),
+	}
+
+	var first []string
+	for i := 0; i < 5; i++ {
+		sink := &recordingSink{}
+		for _, relPath := range sortStringKeys(files) {
+			assert.NoError(t, sink.Write(relPath, files[relPath]))
+		}
+		if first == nil {
+			first = sink.written
+		} else {
+			assert.Equal(t, first, sink.written, "write order must not vary across runs")
+		}
+	}
+}